@@ -0,0 +1,116 @@
+package redistream
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v9"
+	"github.com/pkg/errors"
+)
+
+// batchItem is one enqueued XADD plus the channel its result is delivered
+// on, so Publisher.Publish can stay synchronous from the caller's point of
+// view even though the XADD itself happens as part of a shared pipeline.
+type batchItem struct {
+	args   *redis.XAddArgs
+	result chan error
+}
+
+// topicBatcher pipelines every XADD enqueued for a single topic: entries
+// are flushed together, in enqueue order, either once batchSize of them
+// have queued up or on the next tick of a maxLatency timer that restarts
+// after every flush, whichever comes first, so no entry waits longer than
+// maxLatency.
+type topicBatcher struct {
+	client     redis.UniversalClient
+	batchSize  int
+	maxLatency time.Duration
+
+	items   chan batchItem
+	closing chan struct{}
+}
+
+func newTopicBatcher(client redis.UniversalClient, batchSize int, maxLatency time.Duration) *topicBatcher {
+	return &topicBatcher{
+		client:     client,
+		batchSize:  batchSize,
+		maxLatency: maxLatency,
+		items:      make(chan batchItem),
+		closing:    make(chan struct{}),
+	}
+}
+
+// enqueue blocks until item has been handed to the batcher's run loop, or
+// the batcher is closing.
+func (b *topicBatcher) enqueue(item batchItem) error {
+	select {
+	case b.items <- item:
+		return nil
+	case <-b.closing:
+		return errors.New("publisher is closed")
+	}
+}
+
+func (b *topicBatcher) close() {
+	close(b.closing)
+}
+
+func (b *topicBatcher) run() {
+	pending := make([]batchItem, 0, b.batchSize)
+	timer := time.NewTimer(b.maxLatency)
+	defer timer.Stop()
+
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		b.flush(pending)
+		pending = pending[:0]
+	}
+
+	for {
+		select {
+		case item := <-b.items:
+			pending = append(pending, item)
+			if len(pending) >= b.batchSize {
+				flush()
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(b.maxLatency)
+			}
+
+		case <-timer.C:
+			flush()
+			timer.Reset(b.maxLatency)
+
+		case <-b.closing:
+			flush()
+			return
+		}
+	}
+}
+
+// flush issues every pending XADD as a single redis.Pipeliner round trip,
+// preserving enqueue order (pipeline commands execute in the order they
+// were queued), and reports each item's own result back on its channel.
+func (b *topicBatcher) flush(items []batchItem) {
+	ctx := context.Background()
+	pipe := b.client.Pipeline()
+
+	cmds := make([]*redis.StringCmd, len(items))
+	for i, item := range items {
+		cmds[i] = pipe.XAdd(ctx, item.args)
+	}
+
+	// Exec's own error is also reflected on each individual cmd (go-redis
+	// populates every queued command with the error that aborted the
+	// pipeline, if any), so reporting cmds[i].Err() alone is enough to
+	// tell each item apart - a command that got its own reply is not
+	// blamed for a sibling's failure.
+	_, _ = pipe.Exec(ctx)
+
+	for i, item := range items {
+		item.result <- cmds[i].Err()
+	}
+}
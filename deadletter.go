@@ -0,0 +1,102 @@
+package redistream
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/go-redis/redis/v9"
+	"github.com/pkg/errors"
+)
+
+const (
+	metaOriginalStream  = "x-original-stream"
+	metaDeliveryCount   = "x-delivery-count"
+	metaLastError       = "x-last-error"
+	metaFirstDeliveryMs = "x-first-delivered-ms"
+)
+
+// deadLetterScript XADDs the poison entry to the dead-letter stream and
+// XACKs the original entry in a single call, so a crash between the two
+// writes can never lose the message (it would either still be pending on
+// the original stream, to be retried, or already safely on the DLQ).
+var deadLetterScript = redis.NewScript(`
+redis.call("XADD", KEYS[2], "*", unpack(ARGV, 3))
+return redis.call("XACK", KEYS[1], ARGV[1], ARGV[2])
+`)
+
+// deadLetterStreamName returns SubscriberConfig.DeadLetterStream, or
+// "<topic>.dlq" if it is unset.
+func (c SubscriberConfig) deadLetterStreamName(topic string) string {
+	if c.DeadLetterStream != "" {
+		return c.DeadLetterStream
+	}
+	return topic + ".dlq"
+}
+
+// deadLetter moves entry id off topic and onto its dead-letter stream,
+// preserving the raw marshaled fields plus delivery metadata, and acks the
+// original entry. The two writes happen atomically via deadLetterScript.
+func (s *Subscriber) deadLetter(ctx context.Context, topic, id string, values map[string]interface{}, deliveryCount int64, firstDeliveredMs int64, lastErr error) error {
+	dlqStream := s.config.deadLetterStreamName(topic)
+
+	fields := make([]interface{}, 0, 2*(len(values)+4))
+	for field, value := range values {
+		fields = append(fields, field, value)
+	}
+
+	lastErrMsg := ""
+	if lastErr != nil {
+		lastErrMsg = lastErr.Error()
+	}
+
+	fields = append(fields,
+		metaOriginalStream, topic,
+		metaDeliveryCount, strconv.FormatInt(deliveryCount, 10),
+		metaLastError, lastErrMsg,
+		metaFirstDeliveryMs, strconv.FormatInt(firstDeliveredMs, 10),
+	)
+
+	args := append([]interface{}{s.config.ConsumerGroup, id}, fields...)
+
+	if err := deadLetterScript.Run(ctx, s.client, []string{topic, dlqStream}, args...).Err(); err != nil {
+		return errors.Wrapf(err, "cannot dead-letter entry %s from stream %s to %s", id, topic, dlqStream)
+	}
+
+	return nil
+}
+
+// firstDeliveredMs returns the Unix millisecond timestamp embedded in a
+// Redis stream entry ID's time component, used as the DLQ's
+// x-first-delivered-ms when the original delivery time isn't otherwise
+// tracked.
+func firstDeliveredMs(id string) int64 {
+	parts := id
+	for i, r := range id {
+		if r == '-' {
+			parts = id[:i]
+			break
+		}
+	}
+
+	ms, err := strconv.ParseInt(parts, 10, 64)
+	if err != nil {
+		return time.Now().UnixMilli()
+	}
+
+	return ms
+}
+
+// NewDeadLetterSubscriber returns a Subscriber reading a dead-letter
+// stream with the same Watermill interface as any other Subscriber, so
+// operators can plug it into ordinary Watermill routers/handlers.
+func NewDeadLetterSubscriber(
+	ctx context.Context,
+	config SubscriberConfig,
+	client redis.UniversalClient,
+	marshaler MarshalerUnmarshaler,
+	logger watermill.LoggerAdapter,
+) (*Subscriber, error) {
+	return NewSubscriber(ctx, config, client, marshaler, logger)
+}
@@ -0,0 +1,73 @@
+package redistream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/renstrom/shortuuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriber_DeadLettersAfterMaxDeliveries(t *testing.T) {
+	topic := "test-topic-dlq"
+	consumerGroup := shortuuid.New()
+	ctx := context.Background()
+
+	rc, err := redisClient(ctx)
+	require.NoError(t, err)
+
+	publisher, err := NewPublisher(ctx, PublisherConfig{}, rc, &DefaultMarshaller{}, watermill.NewStdLogger(false, false))
+	require.NoError(t, err)
+	require.NoError(t, publisher.Publish(topic, message.NewMessage(shortuuid.New(), []byte("poison"))))
+	require.NoError(t, publisher.Close())
+
+	// First delivery: never acked, simulating a consumer crash.
+	crashed, err := NewSubscriber(ctx, SubscriberConfig{
+		Consumer:        shortuuid.New(),
+		ConsumerGroup:   consumerGroup,
+		InitialPosition: InitialPositionEarliest,
+	}, rc, &DefaultMarshaller{}, watermill.NewStdLogger(true, false))
+	require.NoError(t, err)
+
+	messages, err := crashed.Subscribe(ctx, topic)
+	require.NoError(t, err)
+	require.NotNil(t, <-messages)
+	require.NoError(t, crashed.Close())
+
+	// Second consumer reaps the pending entry; its delivery count (2)
+	// exceeds MaxDeliveries (1), so it should be dead-lettered rather than
+	// handed to the application.
+	reaper, err := NewSubscriber(ctx, SubscriberConfig{
+		Consumer:        shortuuid.New(),
+		ConsumerGroup:   consumerGroup,
+		InitialPosition: InitialPositionEarliest,
+		ClaimIdleTime:   10 * time.Millisecond,
+		ClaimInterval:   20 * time.Millisecond,
+		MaxDeliveries:   1,
+	}, rc, &DefaultMarshaller{}, watermill.NewStdLogger(true, false))
+	require.NoError(t, err)
+
+	reaperMessages, err := reaper.Subscribe(ctx, topic)
+	require.NoError(t, err)
+	defer reaper.Close()
+
+	require.Eventually(t, func() bool {
+		pending, err := rc.XPending(ctx, topic, consumerGroup).Result()
+		return err == nil && pending.Count == 0
+	}, 5*time.Second, 50*time.Millisecond, "poison entry was never acked off the original stream")
+
+	select {
+	case msg := <-reaperMessages:
+		t.Fatalf("dead-lettered entry should not have been delivered to the application, got %v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	dlqEntries, err := rc.XRange(ctx, topic+".dlq", "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, dlqEntries, 1)
+	require.Equal(t, topic, dlqEntries[0].Values[metaOriginalStream])
+	require.Equal(t, "2", dlqEntries[0].Values[metaDeliveryCount])
+}
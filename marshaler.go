@@ -0,0 +1,87 @@
+package redistream
+
+import (
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack"
+)
+
+const (
+	uuidField     = "uuid"
+	payloadField  = "payload"
+	metadataField = "metadata"
+)
+
+// MarshalerUnmarshaler converts between Watermill messages and the field
+// layout stored in a Redis stream entry.
+type MarshalerUnmarshaler interface {
+	// Marshal returns the values to pass as the XADD field/value pairs for
+	// msg.
+	Marshal(topic string, msg *message.Message) (map[string]interface{}, error)
+	// Unmarshal rebuilds a Watermill message from the field values of a
+	// consumed stream entry.
+	Unmarshal(values map[string]interface{}) (*message.Message, error)
+}
+
+// DefaultMarshaller stores a message as three separate stream fields: the
+// UUID, the raw payload and the metadata, msgpack-encoded.
+type DefaultMarshaller struct{}
+
+func (DefaultMarshaller) Marshal(topic string, msg *message.Message) (map[string]interface{}, error) {
+	metadata, err := msgpack.Marshal(msg.Metadata)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot marshal metadata")
+	}
+
+	return map[string]interface{}{
+		uuidField:     msg.UUID,
+		payloadField:  []byte(msg.Payload),
+		metadataField: metadata,
+	}, nil
+}
+
+func (DefaultMarshaller) Unmarshal(values map[string]interface{}) (*message.Message, error) {
+	uuid, err := stringField(values, uuidField)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := stringField(values, payloadField)
+	if err != nil {
+		return nil, err
+	}
+
+	rawMetadata, err := stringField(values, metadataField)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata message.Metadata
+	if err := msgpack.Unmarshal([]byte(rawMetadata), &metadata); err != nil {
+		return nil, errors.Wrap(err, "cannot unmarshal metadata")
+	}
+
+	msg := message.NewMessage(uuid, []byte(payload))
+	msg.Metadata = metadata
+
+	return msg, nil
+}
+
+// stringField extracts a field that may come back either as a string
+// (already round-tripped through Redis) or as []byte (still in the
+// producer's own representation).
+func stringField(values map[string]interface{}, field string) (string, error) {
+	v, ok := values[field]
+	if !ok {
+		return "", errors.Errorf("missing %q field", field)
+	}
+
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case []byte:
+		return string(t), nil
+	default:
+		return "", errors.Errorf("unexpected type %T for %q field", v, field)
+	}
+}
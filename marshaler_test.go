@@ -26,6 +26,71 @@ func TestDefaultMarshaler_MarshalUnmarshal(t *testing.T) {
 	assert.True(t, msg.Equals(unmarshaledMsg))
 }
 
+// TestMarshaler_RoundTrip runs every MarshalerUnmarshaler through the same
+// round trip, covering a binary payload and metadata that would be
+// corrupted by a naive map-of-interfaces re-encoding of a Redis reply.
+func TestMarshaler_RoundTrip(t *testing.T) {
+	marshalers := map[string]MarshalerUnmarshaler{
+		"DefaultMarshaller": DefaultMarshaller{},
+		"ProtobufMarshaler": ProtobufMarshaler{},
+	}
+
+	payload := []byte{0x00, 0x01, 0xff, 0xfe, 'h', 'i', 0x80}
+
+	for name, m := range marshalers {
+		m := m
+		t.Run(name, func(t *testing.T) {
+			msg := message.NewMessage(watermill.NewUUID(), payload)
+			msg.Metadata.Set("foo", "bar")
+			msg.Metadata.Set("unicode", "héllo wörld 日本語")
+			msg.Metadata.Set("empty", "")
+
+			marshaled, err := m.Marshal("topic", msg)
+			require.NoError(t, err)
+
+			consumerMessage, err := producerToConsumerMessage(marshaled)
+			require.NoError(t, err)
+
+			unmarshaledMsg, err := m.Unmarshal(consumerMessage)
+			require.NoError(t, err)
+
+			assert.True(t, msg.Equals(unmarshaledMsg))
+			assert.Equal(t, msg.Metadata, unmarshaledMsg.Metadata)
+			assert.Equal(t, msg.Payload, unmarshaledMsg.Payload)
+		})
+	}
+}
+
+// TestProtobufMarshaler_ContentTypeAndSchemaID round-trips a message with
+// ContentTypeMetadataKey and SchemaIDMetadataKey set, so the envelope's
+// optional fields 4/5, which TestMarshaler_RoundTrip never populates, are
+// written and parsed. Their values also travel as ordinary metadata
+// entries (field 3), so the assertions hold whether or not fields 4/5
+// are restored on Unmarshal.
+func TestProtobufMarshaler_ContentTypeAndSchemaID(t *testing.T) {
+	m := ProtobufMarshaler{
+		ContentTypeMetadataKey: "content-type",
+		SchemaIDMetadataKey:    "schema-id",
+	}
+
+	msg := message.NewMessage(watermill.NewUUID(), []byte("payload"))
+	msg.Metadata.Set("content-type", "application/x-protobuf")
+	msg.Metadata.Set("schema-id", "order.v1")
+	msg.Metadata.Set("foo", "bar")
+
+	marshaled, err := m.Marshal("topic", msg)
+	require.NoError(t, err)
+
+	consumerMessage, err := producerToConsumerMessage(marshaled)
+	require.NoError(t, err)
+
+	unmarshaledMsg, err := m.Unmarshal(consumerMessage)
+	require.NoError(t, err)
+
+	assert.True(t, msg.Equals(unmarshaledMsg))
+	assert.Equal(t, msg.Metadata, unmarshaledMsg.Metadata)
+}
+
 func BenchmarkDefaultMarshaler_Marshal(b *testing.B) {
 	m := DefaultMarshaller{}
 
@@ -0,0 +1,187 @@
+package redistream
+
+import (
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const envelopeField = "envelope"
+
+// Field numbers of the envelope this marshaler writes. There is no
+// generated .proto for it: the layout is simple and stable enough that
+// encoding/protowire's low-level writer/reader are used directly instead
+// of adding a protoc-gen-go build step for a single message type.
+//
+//	1: uuid          string
+//	2: payload       bytes
+//	3: metadata      repeated {1: key string, 2: value string}
+//	4: content_type  string, optional
+//	5: schema_id     string, optional
+const (
+	envelopeFieldUUID        = protowire.Number(1)
+	envelopeFieldPayload     = protowire.Number(2)
+	envelopeFieldMetadata    = protowire.Number(3)
+	envelopeFieldContentType = protowire.Number(4)
+	envelopeFieldSchemaID    = protowire.Number(5)
+
+	metadataEntryFieldKey   = protowire.Number(1)
+	metadataEntryFieldValue = protowire.Number(2)
+)
+
+// ProtobufMarshaler implements MarshalerUnmarshaler by packing a message's
+// UUID, payload and metadata into a single protobuf-encoded envelope
+// written as one Redis stream field, rather than DefaultMarshaller's
+// multi-field layout. ContentType and SchemaID let a producer tag the
+// payload so a SchemaRegistry-aware consumer can decode it dynamically.
+type ProtobufMarshaler struct {
+	// ContentTypeMetadataKey, if set, names the message.Metadata key whose
+	// value populates (and is restored from) the envelope's optional
+	// content_type field.
+	ContentTypeMetadataKey string
+	// SchemaIDMetadataKey, if set, names the message.Metadata key whose
+	// value populates (and is restored from) the envelope's optional
+	// schema_id field.
+	SchemaIDMetadataKey string
+}
+
+func (m ProtobufMarshaler) Marshal(topic string, msg *message.Message) (map[string]interface{}, error) {
+	var b []byte
+
+	b = protowire.AppendTag(b, envelopeFieldUUID, protowire.BytesType)
+	b = protowire.AppendString(b, msg.UUID)
+
+	b = protowire.AppendTag(b, envelopeFieldPayload, protowire.BytesType)
+	b = protowire.AppendBytes(b, msg.Payload)
+
+	for key, value := range msg.Metadata {
+		b = protowire.AppendTag(b, envelopeFieldMetadata, protowire.BytesType)
+		b = protowire.AppendBytes(b, appendMetadataEntry(nil, key, value))
+	}
+
+	if key := m.ContentTypeMetadataKey; key != "" {
+		if ct := msg.Metadata.Get(key); ct != "" {
+			b = protowire.AppendTag(b, envelopeFieldContentType, protowire.BytesType)
+			b = protowire.AppendString(b, ct)
+		}
+	}
+
+	if key := m.SchemaIDMetadataKey; key != "" {
+		if id := msg.Metadata.Get(key); id != "" {
+			b = protowire.AppendTag(b, envelopeFieldSchemaID, protowire.BytesType)
+			b = protowire.AppendString(b, id)
+		}
+	}
+
+	return map[string]interface{}{envelopeField: b}, nil
+}
+
+func appendMetadataEntry(b []byte, key, value string) []byte {
+	b = protowire.AppendTag(b, metadataEntryFieldKey, protowire.BytesType)
+	b = protowire.AppendString(b, key)
+	b = protowire.AppendTag(b, metadataEntryFieldValue, protowire.BytesType)
+	b = protowire.AppendString(b, value)
+	return b
+}
+
+func (m ProtobufMarshaler) Unmarshal(values map[string]interface{}) (*message.Message, error) {
+	raw, err := stringField(values, envelopeField)
+	if err != nil {
+		return nil, err
+	}
+
+	b := []byte(raw)
+
+	var uuid, contentType, schemaID string
+	var payload []byte
+	metadata := message.Metadata{}
+
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, errors.Wrap(protowire.ParseError(n), "cannot parse envelope tag")
+		}
+		b = b[n:]
+
+		switch num {
+		case envelopeFieldUUID:
+			v, n := protowire.ConsumeString(b)
+			if n < 0 {
+				return nil, errors.Wrap(protowire.ParseError(n), "cannot parse envelope uuid")
+			}
+			uuid, b = v, b[n:]
+		case envelopeFieldPayload:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, errors.Wrap(protowire.ParseError(n), "cannot parse envelope payload")
+			}
+			payload, b = append([]byte(nil), v...), b[n:]
+		case envelopeFieldMetadata:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, errors.Wrap(protowire.ParseError(n), "cannot parse envelope metadata entry")
+			}
+			key, value, err := parseMetadataEntry(v)
+			if err != nil {
+				return nil, err
+			}
+			metadata.Set(key, value)
+			b = b[n:]
+		case envelopeFieldContentType:
+			v, n := protowire.ConsumeString(b)
+			if n < 0 {
+				return nil, errors.Wrap(protowire.ParseError(n), "cannot parse envelope content_type")
+			}
+			contentType, b = v, b[n:]
+		case envelopeFieldSchemaID:
+			v, n := protowire.ConsumeString(b)
+			if n < 0 {
+				return nil, errors.Wrap(protowire.ParseError(n), "cannot parse envelope schema_id")
+			}
+			schemaID, b = v, b[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return nil, errors.Wrap(protowire.ParseError(n), "cannot skip unknown envelope field")
+			}
+			b = b[n:]
+		}
+	}
+
+	if key := m.ContentTypeMetadataKey; key != "" && contentType != "" {
+		metadata.Set(key, contentType)
+	}
+	if key := m.SchemaIDMetadataKey; key != "" && schemaID != "" {
+		metadata.Set(key, schemaID)
+	}
+
+	msg := message.NewMessage(uuid, payload)
+	msg.Metadata = metadata
+
+	return msg, nil
+}
+
+func parseMetadataEntry(b []byte) (key, value string, err error) {
+	for len(b) > 0 {
+		num, _, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return "", "", errors.Wrap(protowire.ParseError(n), "cannot parse metadata entry tag")
+		}
+		b = b[n:]
+
+		v, n := protowire.ConsumeString(b)
+		if n < 0 {
+			return "", "", errors.Wrap(protowire.ParseError(n), "cannot parse metadata entry value")
+		}
+		b = b[n:]
+
+		switch num {
+		case metadataEntryFieldKey:
+			key = v
+		case metadataEntryFieldValue:
+			value = v
+		}
+	}
+
+	return key, value, nil
+}
@@ -0,0 +1,196 @@
+package redistream
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/go-redis/redis/v9"
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxBatchLatency bounds how long an enqueued message waits for a
+// batch to fill when PublisherConfig.MaxBatchLatency is unset.
+const DefaultMaxBatchLatency = 10 * time.Millisecond
+
+// PublisherConfig configures a Publisher.
+type PublisherConfig struct {
+	// Retention, if set, is applied to every XADD so the stream doesn't
+	// grow unbounded. Use a Trimmer instead if retention should run
+	// independently of publish frequency.
+	Retention Retention
+
+	// BatchSize enables pipelined publishing: messages enqueued for the
+	// same topic are flushed together through a single redis.Pipeliner
+	// round trip once BatchSize of them have queued up. Zero (the
+	// default) or one publishes each message with its own synchronous
+	// XADD, as before.
+	BatchSize int
+	// MaxBatchLatency bounds how long an enqueued message can wait for
+	// BatchSize to fill before its batch is flushed anyway. Defaults to
+	// DefaultMaxBatchLatency. Ignored when BatchSize is zero or one.
+	MaxBatchLatency time.Duration
+}
+
+func (c PublisherConfig) maxBatchLatency() time.Duration {
+	if c.MaxBatchLatency > 0 {
+		return c.MaxBatchLatency
+	}
+	return DefaultMaxBatchLatency
+}
+
+// Publisher is a Watermill message.Publisher that XADDs to a Redis stream
+// named after the topic.
+type Publisher struct {
+	config    PublisherConfig
+	client    redis.UniversalClient
+	marshaler MarshalerUnmarshaler
+	logger    watermill.LoggerAdapter
+
+	batchersMu sync.Mutex
+	batchers   map[string]*topicBatcher
+	batchersWg sync.WaitGroup
+
+	closeMu sync.Mutex
+	closed  bool
+}
+
+// NewPublisher creates a Publisher publishing to streams on client.
+func NewPublisher(
+	ctx context.Context,
+	config PublisherConfig,
+	client redis.UniversalClient,
+	marshaler MarshalerUnmarshaler,
+	logger watermill.LoggerAdapter,
+) (*Publisher, error) {
+	if marshaler == nil {
+		marshaler = DefaultMarshaller{}
+	}
+	if logger == nil {
+		logger = watermill.NopLogger{}
+	}
+
+	return &Publisher{
+		config:    config,
+		client:    client,
+		marshaler: marshaler,
+		logger:    logger,
+		batchers:  make(map[string]*topicBatcher),
+	}, nil
+}
+
+func (p *Publisher) Publish(topic string, messages ...*message.Message) error {
+	if p.config.BatchSize <= 1 {
+		return p.publishSync(topic, messages)
+	}
+
+	return p.publishBatched(topic, messages)
+}
+
+func (p *Publisher) publishSync(topic string, messages []*message.Message) error {
+	for _, msg := range messages {
+		args, err := p.buildXAddArgs(topic, msg)
+		if err != nil {
+			return err
+		}
+
+		id, err := p.client.XAdd(context.Background(), args).Result()
+		if err != nil {
+			return errors.Wrapf(err, "cannot XADD message %s to stream %s", msg.UUID, topic)
+		}
+
+		p.logger.Trace("Published message", watermill.LogFields{
+			"uuid": msg.UUID, "topic": topic, "stream": topic, "id": id,
+		})
+	}
+
+	return nil
+}
+
+// publishBatched enqueues every message onto topic's batcher and blocks
+// until each has been flushed, so Publish stays synchronous even though
+// the XADDs happen in a shared pipeline.
+func (p *Publisher) publishBatched(topic string, messages []*message.Message) error {
+	results := make([]chan error, len(messages))
+
+	for i, msg := range messages {
+		args, err := p.buildXAddArgs(topic, msg)
+		if err != nil {
+			return err
+		}
+
+		result := make(chan error, 1)
+		results[i] = result
+
+		if err := p.topicBatcher(topic).enqueue(batchItem{args: args, result: result}); err != nil {
+			return err
+		}
+	}
+
+	var firstErr error
+	for i, result := range results {
+		if err := <-result; err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "cannot XADD message %s to stream %s", messages[i].UUID, topic)
+		}
+	}
+
+	return firstErr
+}
+
+func (p *Publisher) buildXAddArgs(topic string, msg *message.Message) (*redis.XAddArgs, error) {
+	values, err := p.marshaler.Marshal(topic, msg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot marshal message %s", msg.UUID)
+	}
+
+	args := &redis.XAddArgs{
+		Stream: topic,
+		Values: values,
+	}
+	if p.config.Retention != nil {
+		p.config.Retention.applyToAdd(args)
+	}
+
+	return args, nil
+}
+
+func (p *Publisher) topicBatcher(topic string) *topicBatcher {
+	p.batchersMu.Lock()
+	defer p.batchersMu.Unlock()
+
+	b, ok := p.batchers[topic]
+	if !ok {
+		b = newTopicBatcher(p.client, p.config.BatchSize, p.config.maxBatchLatency())
+		p.batchers[topic] = b
+
+		p.batchersWg.Add(1)
+		go func() {
+			defer p.batchersWg.Done()
+			b.run()
+		}()
+	}
+
+	return b
+}
+
+func (p *Publisher) Close() error {
+	p.closeMu.Lock()
+	defer p.closeMu.Unlock()
+
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+
+	p.batchersMu.Lock()
+	for _, b := range p.batchers {
+		b.close()
+	}
+	p.batchersMu.Unlock()
+
+	p.batchersWg.Wait()
+
+	return nil
+}
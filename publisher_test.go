@@ -0,0 +1,83 @@
+package redistream
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/renstrom/shortuuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisher_BatchPreservesOrder(t *testing.T) {
+	ctx := context.Background()
+	rc, err := redisClient(ctx)
+	require.NoError(t, err)
+
+	topic := "test-topic-batch-order-" + shortuuid.New()
+	publisher, err := NewPublisher(ctx, PublisherConfig{
+		BatchSize:       16,
+		MaxBatchLatency: 50 * time.Millisecond,
+	}, rc, &DefaultMarshaller{}, watermill.NewStdLogger(false, false))
+	require.NoError(t, err)
+
+	const count = 200
+	for i := 0; i < count; i++ {
+		require.NoError(t, publisher.Publish(topic, message.NewMessage(shortuuid.New(), []byte(strconv.Itoa(i)))))
+	}
+	require.NoError(t, publisher.Close())
+
+	entries, err := rc.XRange(ctx, topic, "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, entries, count)
+
+	for i, entry := range entries {
+		require.Equal(t, strconv.Itoa(i), entry.Values[payloadField])
+	}
+}
+
+func benchmarkPublisherThroughput(b *testing.B, batchSize int) {
+	ctx := context.Background()
+	rc, err := redisClient(ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	topic := "bench-topic-throughput-" + shortuuid.New()
+	publisher, err := NewPublisher(ctx, PublisherConfig{
+		BatchSize:       batchSize,
+		MaxBatchLatency: 10 * time.Millisecond,
+	}, rc, &DefaultMarshaller{}, watermill.NewStdLogger(false, false))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer publisher.Close()
+
+	msg := message.NewMessage(shortuuid.New(), []byte("payload"))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := publisher.Publish(topic, msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPublisher_Throughput_Single(b *testing.B) {
+	benchmarkPublisherThroughput(b, 1)
+}
+
+func BenchmarkPublisher_Throughput_Batch16(b *testing.B) {
+	benchmarkPublisherThroughput(b, 16)
+}
+
+func BenchmarkPublisher_Throughput_Batch128(b *testing.B) {
+	benchmarkPublisherThroughput(b, 128)
+}
+
+func BenchmarkPublisher_Throughput_Batch1024(b *testing.B) {
+	benchmarkPublisherThroughput(b, 1024)
+}
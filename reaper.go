@@ -0,0 +1,133 @@
+package redistream
+
+import (
+	"context"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/go-redis/redis/v9"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultClaimInterval is how often the pending-entry reaper runs
+	// XAUTOCLAIM when SubscriberConfig.ClaimInterval is unset.
+	DefaultClaimInterval = 5 * time.Second
+	// DefaultClaimBatchSize is the XAUTOCLAIM COUNT used when
+	// SubscriberConfig.ClaimBatchSize is unset.
+	DefaultClaimBatchSize = 100
+)
+
+func (c SubscriberConfig) claimInterval() time.Duration {
+	if c.ClaimInterval > 0 {
+		return c.ClaimInterval
+	}
+	return DefaultClaimInterval
+}
+
+func (c SubscriberConfig) claimBatchSize() int64 {
+	if c.ClaimBatchSize > 0 {
+		return c.ClaimBatchSize
+	}
+	return DefaultClaimBatchSize
+}
+
+// reapLoop periodically reclaims pending entries that have been idle for
+// at least ClaimIdleTime and re-injects them into output through the same
+// unmarshal/ack machinery as the normal read loop, so a consumer that
+// crashed mid-processing doesn't strand its deliveries forever.
+func (s *Subscriber) reapLoop(ctx context.Context, topic string, output chan *message.Message) {
+	ticker := time.NewTicker(s.config.claimInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.closing:
+			return
+		case <-ticker.C:
+			s.claimPending(ctx, topic, output, s.config.ClaimIdleTime)
+		}
+	}
+}
+
+// claimPending runs one XAUTOCLAIM pass over topic's pending entries idle
+// for at least minIdle. It is shared by the background reaper (minIdle is
+// ClaimIdleTime) and SubscriptionTypeFailover's promotion path (minIdle is
+// 0: the previous active consumer's lock has already expired by the time
+// a successor is promoted, so its entries are claimable immediately).
+func (s *Subscriber) claimPending(ctx context.Context, topic string, output chan *message.Message, minIdle time.Duration) {
+	cursor := "0-0"
+
+	for {
+		claimed, next, err := s.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   topic,
+			Group:    s.config.ConsumerGroup,
+			Consumer: s.config.Consumer,
+			MinIdle:  minIdle,
+			Start:    cursor,
+			Count:    s.config.claimBatchSize(),
+		}).Result()
+		if err != nil {
+			s.logger.Error("Cannot XAUTOCLAIM", err, watermill.LogFields{"topic": topic})
+			return
+		}
+
+		toDeliver := claimed[:0]
+		for _, entry := range claimed {
+			if s.isInFlight(entry.ID) {
+				continue
+			}
+
+			if s.config.MaxDeliveries > 0 {
+				deliveries, err := s.deliveryCount(ctx, topic, entry.ID)
+				if err != nil {
+					s.logger.Error("Cannot inspect delivery count", err, watermill.LogFields{"topic": topic, "id": entry.ID})
+					continue
+				}
+				if deliveries > s.config.MaxDeliveries {
+					if err := s.deadLetter(ctx, topic, entry.ID, entry.Values, deliveries, firstDeliveredMs(entry.ID), errors.Errorf("exceeded MaxDeliveries (%d)", s.config.MaxDeliveries)); err != nil {
+						s.logger.Error("Cannot dead-letter entry", err, watermill.LogFields{"topic": topic, "id": entry.ID})
+					}
+					continue
+				}
+			}
+
+			toDeliver = append(toDeliver, entry)
+		}
+
+		s.handleEntries(ctx, topic, toDeliver, output)
+
+		select {
+		case <-s.closing:
+			return
+		default:
+		}
+
+		if next == "0-0" || int64(len(claimed)) < s.config.claimBatchSize() {
+			return
+		}
+		cursor = next
+	}
+}
+
+// deliveryCount returns how many times entry id has been delivered,
+// per XPENDING's retry counter.
+func (s *Subscriber) deliveryCount(ctx context.Context, topic, id string) (int64, error) {
+	pending, err := s.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: topic,
+		Group:  s.config.ConsumerGroup,
+		Start:  id,
+		End:    id,
+		Count:  1,
+	}).Result()
+	if err != nil {
+		return 0, err
+	}
+	if len(pending) == 0 {
+		// Already acked by someone else between the XAUTOCLAIM and here.
+		return 0, nil
+	}
+
+	return pending[0].RetryCount, nil
+}
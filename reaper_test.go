@@ -0,0 +1,76 @@
+package redistream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/renstrom/shortuuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriber_ReapsPendingEntriesFromCrashedConsumer(t *testing.T) {
+	topic := "test-topic-reaper"
+	consumerGroup := shortuuid.New()
+	ctx := context.Background()
+
+	rc, err := redisClient(ctx)
+	require.NoError(t, err)
+
+	publisher, err := NewPublisher(ctx, PublisherConfig{}, rc, &DefaultMarshaller{}, watermill.NewStdLogger(false, false))
+	require.NoError(t, err)
+
+	const messageCount = 10
+	for i := 0; i < messageCount; i++ {
+		require.NoError(t, publisher.Publish(topic, message.NewMessage(shortuuid.New(), []byte("test"))))
+	}
+	require.NoError(t, publisher.Close())
+
+	reaperConfig := func(consumer string) SubscriberConfig {
+		return SubscriberConfig{
+			Consumer:        consumer,
+			ConsumerGroup:   consumerGroup,
+			InitialPosition: InitialPositionEarliest,
+			ClaimIdleTime:   100 * time.Millisecond,
+			ClaimInterval:   50 * time.Millisecond,
+			ClaimBatchSize:  100,
+		}
+	}
+
+	crashed, err := NewSubscriber(ctx, reaperConfig(shortuuid.New()), rc, &DefaultMarshaller{}, watermill.NewStdLogger(true, false))
+	require.NoError(t, err)
+
+	messages, err := crashed.Subscribe(ctx, topic)
+	require.NoError(t, err)
+
+	// Read every message but never ack it, then close without acking -
+	// simulating a consumer that crashed mid-processing. The entries stay
+	// in the group's pending entries list.
+	for i := 0; i < messageCount; i++ {
+		msg := <-messages
+		require.NotNil(t, msg)
+	}
+	require.NoError(t, crashed.Close())
+
+	survivor, err := NewSubscriber(ctx, reaperConfig(shortuuid.New()), rc, &DefaultMarshaller{}, watermill.NewStdLogger(true, false))
+	require.NoError(t, err)
+
+	survivorMessages, err := survivor.Subscribe(ctx, topic)
+	require.NoError(t, err)
+	defer survivor.Close()
+
+	received := 0
+	timeout := time.After(5 * time.Second)
+	for received < messageCount {
+		select {
+		case msg := <-survivorMessages:
+			require.NotNil(t, msg)
+			msg.Ack()
+			received++
+		case <-timeout:
+			t.Fatalf("only reclaimed %d/%d pending messages within 5s", received, messageCount)
+		}
+	}
+}
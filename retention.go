@@ -0,0 +1,94 @@
+package redistream
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v9"
+)
+
+// Retention trims a stream as part of publishing to it, or (via Trimmer)
+// on its own schedule.
+type Retention interface {
+	// applyToAdd sets the XADD trim option(s) this Retention represents on
+	// args, for Publisher.Publish; trim runs the equivalent XTRIM directly
+	// against client/topic, for a Trimmer.
+	applyToAdd(args *redis.XAddArgs)
+	trim(ctx context.Context, client redis.UniversalClient, topic string) error
+}
+
+type maxLenRetention struct {
+	n      int64
+	approx bool
+}
+
+// MaxLen keeps at most n entries on the stream. With approx true it uses
+// Redis's "~" form (MAXLEN ~ n / XTRIM MAXLEN ~ n), which trims using
+// the radix-tree node boundary instead of exactly n entries, trading
+// precision for throughput.
+func MaxLen(n int64, approx bool) Retention {
+	return maxLenRetention{n: n, approx: approx}
+}
+
+func (r maxLenRetention) applyToAdd(args *redis.XAddArgs) {
+	args.MaxLen = r.n
+	args.Approx = r.approx
+}
+
+func (r maxLenRetention) trim(ctx context.Context, client redis.UniversalClient, topic string) error {
+	if r.approx {
+		return client.XTrimMaxLenApprox(ctx, topic, r.n, 0).Err()
+	}
+	return client.XTrimMaxLen(ctx, topic, r.n).Err()
+}
+
+type minIDRetention struct {
+	idGenerator func(topic string) string
+}
+
+// MinID evicts every entry older than idGenerator(topic)'s return value,
+// evaluated at the time of each XADD/XTRIM. A common idGenerator derives a
+// Redis stream ID from a wall-clock cutoff, e.g. time-based retention
+// windows.
+func MinID(idGenerator func(topic string) string) Retention {
+	return minIDRetention{idGenerator: idGenerator}
+}
+
+func (r minIDRetention) applyToAdd(args *redis.XAddArgs) {
+	args.MinID = r.idGenerator(args.Stream)
+}
+
+func (r minIDRetention) trim(ctx context.Context, client redis.UniversalClient, topic string) error {
+	return client.XTrimMinID(ctx, topic, r.idGenerator(topic)).Err()
+}
+
+// Trimmer runs a Retention policy against a fixed list of streams on its
+// own schedule, decoupled from publish frequency - useful for consumers
+// that want retention enforced even while nothing is being published.
+type Trimmer struct {
+	client    redis.UniversalClient
+	retention Retention
+	streams   []string
+}
+
+// NewTrimmer creates a Trimmer that applies retention to streams.
+func NewTrimmer(client redis.UniversalClient, retention Retention, streams ...string) *Trimmer {
+	return &Trimmer{
+		client:    client,
+		retention: retention,
+		streams:   streams,
+	}
+}
+
+// Trim runs XTRIM once against every configured stream, returning the
+// first error encountered (if any), after attempting every stream.
+func (t *Trimmer) Trim(ctx context.Context) error {
+	var firstErr error
+
+	for _, stream := range t.streams {
+		if err := t.retention.trim(ctx, t.client, stream); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
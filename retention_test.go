@@ -0,0 +1,41 @@
+package redistream
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/renstrom/shortuuid"
+)
+
+func benchmarkPublisherRetention(b *testing.B, retention Retention) {
+	ctx := context.Background()
+	rc, err := redisClient(ctx)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	topic := "bench-topic-retention-" + shortuuid.New()
+	publisher, err := NewPublisher(ctx, PublisherConfig{Retention: retention}, rc, &DefaultMarshaller{}, watermill.NewStdLogger(false, false))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	msg := message.NewMessage(shortuuid.New(), []byte("payload"))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := publisher.Publish(topic, msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPublisher_Retention_MaxLenApprox(b *testing.B) {
+	benchmarkPublisherRetention(b, MaxLen(1000, true))
+}
+
+func BenchmarkPublisher_Retention_MaxLenExact(b *testing.B) {
+	benchmarkPublisherRetention(b, MaxLen(1000, false))
+}
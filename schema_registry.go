@@ -0,0 +1,42 @@
+package redistream
+
+import (
+	"sync"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// SchemaRegistry maps a schema_id, as embedded in a ProtobufMarshaler
+// envelope, to the protobuf message descriptor a downstream consumer
+// should use to decode that entry's payload dynamically.
+type SchemaRegistry interface {
+	Register(id string, desc protoreflect.MessageDescriptor)
+	Lookup(id string) (protoreflect.MessageDescriptor, bool)
+}
+
+// NewSchemaRegistry returns an in-memory, concurrency-safe SchemaRegistry.
+func NewSchemaRegistry() SchemaRegistry {
+	return &inMemorySchemaRegistry{
+		schemas: make(map[string]protoreflect.MessageDescriptor),
+	}
+}
+
+type inMemorySchemaRegistry struct {
+	mu      sync.RWMutex
+	schemas map[string]protoreflect.MessageDescriptor
+}
+
+func (r *inMemorySchemaRegistry) Register(id string, desc protoreflect.MessageDescriptor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.schemas[id] = desc
+}
+
+func (r *inMemorySchemaRegistry) Lookup(id string) (protoreflect.MessageDescriptor, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	desc, ok := r.schemas[id]
+	return desc, ok
+}
@@ -0,0 +1,49 @@
+package redistream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestSchemaRegistry_RegisterLookup(t *testing.T) {
+	registry := NewSchemaRegistry()
+
+	_, ok := registry.Lookup("order.v1")
+	assert.False(t, ok)
+
+	desc := testMessageDescriptor(t, "OrderV1")
+	registry.Register("order.v1", desc)
+
+	got, ok := registry.Lookup("order.v1")
+	require.True(t, ok)
+	assert.Equal(t, desc.FullName(), got.FullName())
+
+	_, ok = registry.Lookup("unknown")
+	assert.False(t, ok)
+}
+
+// testMessageDescriptor builds a minimal, self-contained message
+// descriptor for name, without depending on any generated .proto package.
+func testMessageDescriptor(t *testing.T, name string) protoreflect.MessageDescriptor {
+	t.Helper()
+
+	fileDesc := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String(name + ".proto"),
+		Syntax:  proto.String("proto3"),
+		Package: proto.String("redistreamtest"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String(name)},
+		},
+	}
+
+	fd, err := protodesc.NewFile(fileDesc, nil)
+	require.NoError(t, err)
+
+	return fd.Messages().Get(0)
+}
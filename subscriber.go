@@ -0,0 +1,627 @@
+package redistream
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/go-redis/redis/v9"
+	"github.com/pkg/errors"
+)
+
+// SubscriptionType controls how a Subscriber attaches to a stream's
+// consumer group. It is meaningless when SubscriberConfig.ConsumerGroup is
+// empty.
+type SubscriptionType int
+
+const (
+	// SubscriptionTypeShared load-balances stream entries across every
+	// consumer attached to ConsumerGroup. This is this package's original,
+	// implicit behaviour.
+	SubscriptionTypeShared SubscriptionType = iota
+	// SubscriptionTypeExclusive requires that no other consumer is already
+	// attached to the stream+group pair: Subscribe returns an error if one
+	// is found.
+	SubscriptionTypeExclusive
+	// SubscriptionTypeFailover keeps a single consumer of the group active
+	// at a time: only the lock holder ever reads from the stream. Once the
+	// active consumer stops sending heartbeats and its lock expires, the
+	// consumer that is promoted reclaims every entry still pending under
+	// the previous owner's name via XAUTOCLAIM before reading new entries,
+	// so nothing it had in flight is stranded.
+	SubscriptionTypeFailover
+)
+
+// InitialPosition controls where a Subscriber starts reading a stream the
+// first time it sees it. The zero value, InitialPositionDefault, resolves
+// differently depending on whether ConsumerGroup is set: consumer-group
+// subscriptions default to InitialPositionEarliest (preserving this
+// package's original, implicit "0" behaviour - a freshly created group
+// should not miss messages published before anyone subscribed), while
+// group-less fan-out defaults to InitialPositionLatest (a fan-out
+// subscriber has no durable cursor, so "every entry ever published" is
+// rarely what's wanted and would keep growing on every reconnect).
+type InitialPosition int
+
+const (
+	// InitialPositionDefault resolves to InitialPositionEarliest for
+	// consumer-group subscriptions and InitialPositionLatest for
+	// group-less fan-out. See the InitialPosition doc comment.
+	InitialPositionDefault InitialPosition = iota
+	// InitialPositionLatest only delivers entries added after the
+	// subscriber starts reading, i.e. the consumer group is created at id
+	// "$", or group-less fan-out XREADs from "$".
+	InitialPositionLatest
+	// InitialPositionEarliest delivers every entry still retained on the
+	// stream, i.e. id "0" ("0-0" for group-less fan-out).
+	InitialPositionEarliest
+)
+
+// DefaultBlockTime is how long a single XREAD/XREADGROUP call blocks
+// waiting for new entries before the read loop checks for Close() and
+// retries.
+const DefaultBlockTime = 100 * time.Millisecond
+
+// DefaultFailoverHeartbeat is how often an active SubscriptionTypeFailover
+// consumer refreshes its lock when SubscriberConfig.FailoverHeartbeat is
+// unset, and DefaultFailoverHeartbeatTTL is how long the lock survives
+// without a refresh before another consumer may claim it.
+const (
+	DefaultFailoverHeartbeat    = 2 * time.Second
+	DefaultFailoverHeartbeatTTL = 6 * time.Second
+)
+
+// SubscriberConfig configures a Subscriber.
+type SubscriberConfig struct {
+	// Consumer is this subscriber's consumer name within ConsumerGroup.
+	Consumer string
+	// ConsumerGroup is the Redis consumer group name. Leave empty for
+	// group-less fan-out, where every Subscriber receives every message.
+	ConsumerGroup string
+
+	// SubscriptionType selects how Consumer attaches to ConsumerGroup.
+	// Defaults to SubscriptionTypeShared. Ignored when ConsumerGroup is
+	// empty.
+	SubscriptionType SubscriptionType
+	// InitialPosition selects where to start reading a stream the first
+	// time this ConsumerGroup (or, for fan-out, this Subscriber) sees it.
+	// Defaults to InitialPositionEarliest for consumer-group subscriptions
+	// and InitialPositionLatest for fan-out; see InitialPositionDefault.
+	InitialPosition InitialPosition
+
+	// BlockTime overrides DefaultBlockTime.
+	BlockTime time.Duration
+
+	// ClaimIdleTime is the XAUTOCLAIM min-idle-time: pending entries that
+	// have not been acked for at least this long are eligible for
+	// reclaiming by the pending-entry reaper. Leave zero to disable the
+	// reaper.
+	ClaimIdleTime time.Duration
+	// ClaimInterval is how often the reaper runs XAUTOCLAIM. Defaults to
+	// DefaultClaimInterval.
+	ClaimInterval time.Duration
+	// ClaimBatchSize is the XAUTOCLAIM COUNT per call. Defaults to
+	// DefaultClaimBatchSize.
+	ClaimBatchSize int64
+	// MaxDeliveries caps how many times an entry may be delivered (per
+	// XPENDING's delivery counter) before the reaper moves it to
+	// DeadLetterStream instead of re-injecting it. Zero means unlimited.
+	MaxDeliveries int64
+	// DeadLetterStream is where entries exceeding MaxDeliveries are XADDed
+	// before being XACKed off topic. Defaults to "<topic>.dlq".
+	DeadLetterStream string
+
+	// FailoverHeartbeat is how often an active SubscriptionTypeFailover
+	// consumer refreshes its lock. Defaults to DefaultFailoverHeartbeat.
+	// Ignored unless SubscriptionType is SubscriptionTypeFailover.
+	FailoverHeartbeat time.Duration
+	// FailoverHeartbeatTTL is how long a SubscriptionTypeFailover
+	// consumer's lock survives without a refresh before another consumer
+	// may claim it. Defaults to DefaultFailoverHeartbeatTTL. Ignored
+	// unless SubscriptionType is SubscriptionTypeFailover.
+	FailoverHeartbeatTTL time.Duration
+}
+
+func (c SubscriberConfig) blockTime() time.Duration {
+	if c.BlockTime > 0 {
+		return c.BlockTime
+	}
+	return DefaultBlockTime
+}
+
+func (c SubscriberConfig) failoverHeartbeat() time.Duration {
+	if c.FailoverHeartbeat > 0 {
+		return c.FailoverHeartbeat
+	}
+	return DefaultFailoverHeartbeat
+}
+
+func (c SubscriberConfig) failoverHeartbeatTTL() time.Duration {
+	if c.FailoverHeartbeatTTL > 0 {
+		return c.FailoverHeartbeatTTL
+	}
+	return DefaultFailoverHeartbeatTTL
+}
+
+func (c SubscriberConfig) initialGroupID() string {
+	if c.InitialPosition == InitialPositionLatest {
+		return "$"
+	}
+	// InitialPositionDefault and InitialPositionEarliest both start a
+	// consumer group from the beginning of the stream.
+	return "0"
+}
+
+func (c SubscriberConfig) initialStreamID() string {
+	if c.InitialPosition == InitialPositionEarliest {
+		return "0-0"
+	}
+	// InitialPositionDefault and InitialPositionLatest both start
+	// group-less fan-out from the tail of the stream.
+	return "$"
+}
+
+// Subscriber is a Watermill message.Subscriber backed by a Redis stream.
+type Subscriber struct {
+	config    SubscriberConfig
+	client    redis.UniversalClient
+	marshaler MarshalerUnmarshaler
+	logger    watermill.LoggerAdapter
+
+	closing chan struct{}
+	closed  bool
+	closeMu sync.Mutex
+	subsWg  sync.WaitGroup
+
+	// inFlight tracks entry IDs currently held by a waitAck goroutine, so
+	// the reaper does not re-inject an entry this same subscriber is
+	// already about to ack.
+	inFlight   map[string]struct{}
+	inFlightMu sync.Mutex
+
+	// exclusiveTopics records every topic this Subscriber has taken an
+	// exclusive consumer-group subscription on, so Close can deregister
+	// the consumer name from each - otherwise it lingers in XINFO
+	// CONSUMERS forever and checkNoOtherConsumer would refuse every future
+	// exclusive Subscribe for that topic+group.
+	exclusiveTopics   map[string]struct{}
+	exclusiveTopicsMu sync.Mutex
+}
+
+// NewSubscriber creates a Subscriber reading from streams on client.
+func NewSubscriber(
+	ctx context.Context,
+	config SubscriberConfig,
+	client redis.UniversalClient,
+	marshaler MarshalerUnmarshaler,
+	logger watermill.LoggerAdapter,
+) (*Subscriber, error) {
+	if marshaler == nil {
+		marshaler = DefaultMarshaller{}
+	}
+	if logger == nil {
+		logger = watermill.NopLogger{}
+	}
+
+	return &Subscriber{
+		config:    config,
+		client:    client,
+		marshaler: marshaler,
+		logger:    logger,
+		closing:   make(chan struct{}),
+		inFlight:  make(map[string]struct{}),
+	}, nil
+}
+
+func (s *Subscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	if s.config.ConsumerGroup != "" {
+		if err := s.ensureGroup(ctx, topic); err != nil {
+			return nil, err
+		}
+
+		if s.config.SubscriptionType == SubscriptionTypeExclusive {
+			if err := s.checkNoOtherConsumer(ctx, topic); err != nil {
+				return nil, err
+			}
+			s.trackExclusiveTopic(topic)
+		}
+	}
+
+	output := make(chan *message.Message)
+	var loopWg sync.WaitGroup
+
+	// The background reaper is only safe for SubscriptionTypeShared and
+	// SubscriptionTypeExclusive, where every live consumer is allowed to
+	// claim pending entries. For SubscriptionTypeFailover, claiming must
+	// stay confined to whichever consumer currently holds the failover
+	// lock - otherwise a standby could claim and deliver entries
+	// concurrently with the active consumer, defeating the single-active
+	// guarantee. runActiveFailover reclaims the previous owner's pending
+	// entries itself once promoted, so no separate reaper runs here.
+	if s.config.ConsumerGroup != "" && s.config.ClaimIdleTime > 0 && s.config.SubscriptionType != SubscriptionTypeFailover {
+		loopWg.Add(1)
+		s.subsWg.Add(1)
+		go func() {
+			defer s.subsWg.Done()
+			defer loopWg.Done()
+			s.reapLoop(ctx, topic, output)
+		}()
+	}
+
+	loopWg.Add(1)
+	s.subsWg.Add(1)
+	go func() {
+		defer s.subsWg.Done()
+		defer loopWg.Done()
+
+		switch {
+		case s.config.ConsumerGroup == "":
+			s.fanOutLoop(ctx, topic, output)
+		case s.config.SubscriptionType == SubscriptionTypeFailover:
+			s.failoverLoop(ctx, topic, output)
+		default:
+			s.groupLoop(ctx, topic, output)
+		}
+	}()
+
+	// Only close output once every goroutine that might write to it has
+	// stopped, so the reaper and the main read loop can run concurrently
+	// without racing on a closed channel.
+	s.subsWg.Add(1)
+	go func() {
+		defer s.subsWg.Done()
+		loopWg.Wait()
+		close(output)
+	}()
+
+	return output, nil
+}
+
+func (s *Subscriber) Close() error {
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	close(s.closing)
+	s.subsWg.Wait()
+
+	s.exclusiveTopicsMu.Lock()
+	topics := s.exclusiveTopics
+	s.exclusiveTopicsMu.Unlock()
+
+	for topic := range topics {
+		if err := s.client.XGroupDelConsumer(context.Background(), topic, s.config.ConsumerGroup, s.config.Consumer).Err(); err != nil {
+			s.logger.Error("Cannot deregister consumer", err, watermill.LogFields{"topic": topic, "consumer": s.config.Consumer})
+		}
+	}
+
+	return nil
+}
+
+func (s *Subscriber) trackExclusiveTopic(topic string) {
+	s.exclusiveTopicsMu.Lock()
+	defer s.exclusiveTopicsMu.Unlock()
+
+	if s.exclusiveTopics == nil {
+		s.exclusiveTopics = make(map[string]struct{})
+	}
+	s.exclusiveTopics[topic] = struct{}{}
+}
+
+func (s *Subscriber) ensureGroup(ctx context.Context, topic string) error {
+	err := s.client.XGroupCreateMkStream(ctx, topic, s.config.ConsumerGroup, s.config.initialGroupID()).Err()
+	if err != nil && !isBusyGroupErr(err) {
+		return errors.Wrapf(err, "cannot create consumer group %s on stream %s", s.config.ConsumerGroup, topic)
+	}
+
+	return nil
+}
+
+// checkNoOtherConsumer returns an error if a consumer other than this one
+// is already attached to the stream+group pair.
+func (s *Subscriber) checkNoOtherConsumer(ctx context.Context, topic string) error {
+	consumers, err := s.client.XInfoConsumers(ctx, topic, s.config.ConsumerGroup).Result()
+	if err != nil {
+		return errors.Wrapf(err, "cannot inspect consumers of group %s on stream %s", s.config.ConsumerGroup, topic)
+	}
+
+	for _, consumer := range consumers {
+		if consumer.Name != s.config.Consumer {
+			return errors.Errorf(
+				"exclusive subscription requested, but consumer %q is already attached to group %s on stream %s",
+				consumer.Name, s.config.ConsumerGroup, topic,
+			)
+		}
+	}
+
+	return nil
+}
+
+// groupLoop is the SubscriptionTypeShared read loop: XREADGROUP, with
+// every consumer in the group competing for entries.
+func (s *Subscriber) groupLoop(ctx context.Context, topic string, output chan *message.Message) {
+	for {
+		select {
+		case <-s.closing:
+			return
+		default:
+		}
+
+		streams, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    s.config.ConsumerGroup,
+			Consumer: s.config.Consumer,
+			Streams:  []string{topic, ">"},
+			Count:    100,
+			Block:    s.config.blockTime(),
+		}).Result()
+		if err != nil {
+			if err != redis.Nil {
+				s.logger.Error("Cannot XREADGROUP", err, watermill.LogFields{"topic": topic})
+			}
+			continue
+		}
+
+		for _, stream := range streams {
+			s.handleEntries(ctx, topic, stream.Messages, output)
+		}
+	}
+}
+
+// fanOutLoop is the group-less read loop: every subscriber independently
+// XREADs the stream and receives every entry.
+func (s *Subscriber) fanOutLoop(ctx context.Context, topic string, output chan *message.Message) {
+	lastID := s.config.initialStreamID()
+
+	for {
+		select {
+		case <-s.closing:
+			return
+		default:
+		}
+
+		streams, err := s.client.XRead(ctx, &redis.XReadArgs{
+			Streams: []string{topic, lastID},
+			Count:   100,
+			Block:   s.config.blockTime(),
+		}).Result()
+		if err != nil {
+			if err != redis.Nil {
+				s.logger.Error("Cannot XREAD", err, watermill.LogFields{"topic": topic})
+			}
+			continue
+		}
+
+		for _, stream := range streams {
+			if len(stream.Messages) == 0 {
+				continue
+			}
+			s.handleEntries(ctx, topic, stream.Messages, output)
+			lastID = stream.Messages[len(stream.Messages)-1].ID
+		}
+	}
+}
+
+func (s *Subscriber) handleEntries(ctx context.Context, topic string, entries []redis.XMessage, output chan *message.Message) {
+	for _, entry := range entries {
+		msg, err := s.marshaler.Unmarshal(entry.Values)
+		if err != nil {
+			s.logger.Error("Cannot unmarshal message", err, watermill.LogFields{"topic": topic, "id": entry.ID})
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		msg.SetContext(ctx)
+
+		if s.config.ConsumerGroup != "" {
+			s.trackInFlight(entry.ID)
+
+			s.subsWg.Add(1)
+			go func(id string) {
+				defer s.subsWg.Done()
+				defer cancel()
+				defer s.untrackInFlight(id)
+				s.waitAck(ctx, topic, id, msg)
+			}(entry.ID)
+		} else {
+			cancel()
+		}
+
+		select {
+		case output <- msg:
+		case <-s.closing:
+			return
+		}
+	}
+}
+
+func (s *Subscriber) waitAck(ctx context.Context, topic, id string, msg *message.Message) {
+	select {
+	case <-msg.Acked():
+		if err := s.client.XAck(context.Background(), topic, s.config.ConsumerGroup, id).Err(); err != nil {
+			s.logger.Error("Cannot XACK message", err, watermill.LogFields{"topic": topic, "id": id})
+		}
+	case <-msg.Nacked():
+		// Leave the entry pending. The read loops only XREADGROUP with
+		// ">", which never returns pending entries, so it is redelivered
+		// only once reclaimed by the pending-entry reaper or by a
+		// SubscriptionTypeFailover promotion.
+	case <-s.closing:
+	case <-ctx.Done():
+	}
+}
+
+// failoverKey is the lock key a SubscriptionTypeFailover consumer holds
+// while it is the active reader of topic+ConsumerGroup.
+func (s *Subscriber) failoverKey(topic string) string {
+	return topic + ":" + s.config.ConsumerGroup + ":active-consumer"
+}
+
+// refreshFailoverScript extends the failover lock's TTL only if it is
+// still held by the caller, so a consumer that lost the lock (and may be
+// about to be replaced) can never steal it back from its successor.
+var refreshFailoverScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+func (s *Subscriber) acquireFailoverLock(ctx context.Context, key string) bool {
+	ok, err := s.client.SetNX(ctx, key, s.config.Consumer, s.config.failoverHeartbeatTTL()).Result()
+	if err != nil {
+		s.logger.Error("Cannot acquire failover lock", err, watermill.LogFields{"key": key})
+		return false
+	}
+
+	return ok
+}
+
+// failoverLoop implements SubscriptionTypeFailover: it waits to become the
+// single active consumer of ConsumerGroup, reads the stream while it holds
+// that role, and falls back to trying to reacquire the lock if it is lost
+// (e.g. the process stalled long enough for its heartbeat to expire).
+func (s *Subscriber) failoverLoop(ctx context.Context, topic string, output chan *message.Message) {
+	key := s.failoverKey(topic)
+
+	for {
+		select {
+		case <-s.closing:
+			return
+		default:
+		}
+
+		if !s.acquireFailoverLock(ctx, key) {
+			select {
+			case <-s.closing:
+				return
+			case <-time.After(s.config.blockTime()):
+			}
+			continue
+		}
+
+		s.runActiveFailover(ctx, topic, key, output)
+	}
+}
+
+// runActiveFailover runs while this Subscriber holds the failover lock.
+// Reaching this point means the previous active consumer's heartbeat has
+// already expired (acquireFailoverLock only succeeds once the lock key is
+// gone), so whatever it left pending is, by definition, abandoned: claim
+// it immediately, before reading anything new, so nothing it had in
+// flight is stranded under a name that will never ack it.
+func (s *Subscriber) runActiveFailover(ctx context.Context, topic, key string, output chan *message.Message) {
+	s.claimPending(ctx, topic, output, 0)
+
+	lost := make(chan struct{})
+	stop := make(chan struct{})
+
+	var bgWg sync.WaitGroup
+	bgWg.Add(1)
+	go func() {
+		defer bgWg.Done()
+
+		ticker := time.NewTicker(s.config.failoverHeartbeat())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				ok, err := refreshFailoverScript.Run(
+					ctx, s.client, []string{key}, s.config.Consumer, s.config.failoverHeartbeatTTL().Milliseconds(),
+				).Int()
+				if err != nil || ok == 0 {
+					close(lost)
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	// The opt-in pending-entry reaper (ClaimIdleTime: "leave zero to
+	// disable the reaper") applies here too: only the active consumer
+	// ever runs it, so - unlike the generic reaper in Subscribe, which is
+	// unconditionally disabled for SubscriptionTypeFailover - it can't
+	// race a standby. It still defaults to off, same as Shared/Exclusive.
+	if s.config.ClaimIdleTime > 0 {
+		bgWg.Add(1)
+		go func() {
+			defer bgWg.Done()
+
+			ticker := time.NewTicker(s.config.claimInterval())
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					s.claimPending(ctx, topic, output, s.config.ClaimIdleTime)
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+
+	defer func() {
+		close(stop)
+		bgWg.Wait()
+	}()
+
+	for {
+		select {
+		case <-s.closing:
+			return
+		case <-lost:
+			return
+		default:
+		}
+
+		streams, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    s.config.ConsumerGroup,
+			Consumer: s.config.Consumer,
+			Streams:  []string{topic, ">"},
+			Count:    100,
+			Block:    s.config.blockTime(),
+		}).Result()
+		if err != nil {
+			if err != redis.Nil {
+				s.logger.Error("Cannot XREADGROUP", err, watermill.LogFields{"topic": topic})
+			}
+			continue
+		}
+
+		for _, stream := range streams {
+			s.handleEntries(ctx, topic, stream.Messages, output)
+		}
+	}
+}
+
+func (s *Subscriber) trackInFlight(id string) {
+	s.inFlightMu.Lock()
+	s.inFlight[id] = struct{}{}
+	s.inFlightMu.Unlock()
+}
+
+func (s *Subscriber) untrackInFlight(id string) {
+	s.inFlightMu.Lock()
+	delete(s.inFlight, id)
+	s.inFlightMu.Unlock()
+}
+
+func (s *Subscriber) isInFlight(id string) bool {
+	s.inFlightMu.Lock()
+	_, ok := s.inFlight[id]
+	s.inFlightMu.Unlock()
+	return ok
+}
+
+func isBusyGroupErr(err error) bool {
+	return err != nil && errors.Cause(err).Error() == "BUSYGROUP Consumer Group name already exists"
+}
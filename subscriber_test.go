@@ -0,0 +1,98 @@
+package redistream
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/renstrom/shortuuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriber_ExclusiveRejectsSecondConsumer(t *testing.T) {
+	topic := "test-topic-exclusive"
+	consumerGroup := shortuuid.New()
+	ctx := context.Background()
+
+	rc, err := redisClient(ctx)
+	require.NoError(t, err)
+
+	exclusiveConfig := func(consumer string) SubscriberConfig {
+		return SubscriberConfig{
+			Consumer:         consumer,
+			ConsumerGroup:    consumerGroup,
+			SubscriptionType: SubscriptionTypeExclusive,
+		}
+	}
+
+	first, err := NewSubscriber(ctx, exclusiveConfig(shortuuid.New()), rc, &DefaultMarshaller{}, watermill.NewStdLogger(true, false))
+	require.NoError(t, err)
+
+	_, err = first.Subscribe(ctx, topic)
+	require.NoError(t, err)
+	defer first.Close()
+
+	// Give first's read loop a chance to issue its first XREADGROUP, which
+	// is what registers it as a consumer of the group in Redis.
+	time.Sleep(2 * DefaultBlockTime)
+
+	second, err := NewSubscriber(ctx, exclusiveConfig(shortuuid.New()), rc, &DefaultMarshaller{}, watermill.NewStdLogger(true, false))
+	require.NoError(t, err)
+
+	_, err = second.Subscribe(ctx, topic)
+	require.Error(t, err)
+}
+
+func TestSubscriber_FailoverPromotesAndReclaimsPending(t *testing.T) {
+	topic := "test-topic-failover"
+	consumerGroup := shortuuid.New()
+	ctx := context.Background()
+
+	rc, err := redisClient(ctx)
+	require.NoError(t, err)
+
+	publisher, err := NewPublisher(ctx, PublisherConfig{}, rc, &DefaultMarshaller{}, watermill.NewStdLogger(false, false))
+	require.NoError(t, err)
+	require.NoError(t, publisher.Publish(topic, message.NewMessage(shortuuid.New(), []byte("failover-test"))))
+	require.NoError(t, publisher.Close())
+
+	failoverConfig := func(consumer string) SubscriberConfig {
+		return SubscriberConfig{
+			Consumer:             consumer,
+			ConsumerGroup:        consumerGroup,
+			SubscriptionType:     SubscriptionTypeFailover,
+			FailoverHeartbeat:    30 * time.Millisecond,
+			FailoverHeartbeatTTL: 100 * time.Millisecond,
+		}
+	}
+
+	active, err := NewSubscriber(ctx, failoverConfig(shortuuid.New()), rc, &DefaultMarshaller{}, watermill.NewStdLogger(true, false))
+	require.NoError(t, err)
+
+	activeMessages, err := active.Subscribe(ctx, topic)
+	require.NoError(t, err)
+
+	// Becomes the active consumer and is handed the message published
+	// above, but is closed before acking it - simulating a crash while the
+	// message is in flight. The entry stays pending under active's name.
+	msg := <-activeMessages
+	require.NotNil(t, msg)
+	require.NoError(t, active.Close())
+
+	standby, err := NewSubscriber(ctx, failoverConfig(shortuuid.New()), rc, &DefaultMarshaller{}, watermill.NewStdLogger(true, false))
+	require.NoError(t, err)
+
+	standbyMessages, err := standby.Subscribe(ctx, topic)
+	require.NoError(t, err)
+	defer standby.Close()
+
+	select {
+	case reclaimed := <-standbyMessages:
+		require.NotNil(t, reclaimed)
+		reclaimed.Ack()
+	case <-time.After(5 * time.Second):
+		t.Fatal("standby never took over and reclaimed the pending entry after the active consumer's lock expired")
+	}
+}